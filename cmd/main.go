@@ -4,13 +4,16 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
+	"bitly/internal/domain"
 	"bitly/internal/handler"
 	"bitly/internal/repository"
+	"bitly/internal/repository/migrations"
 	"bitly/internal/service"
 
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -19,67 +22,127 @@ const (
 	MongoURI 		= ""
 	DBName       = "url_shortener"
 	Port         = ":8081"
-	collectionName = "urls"
+
+	// ShortCodeMode selects how short codes are generated: service.CodeGenCounter (the
+	// default, collision-free) or service.CodeGenRandom (legacy retry-on-collision mode).
+	ShortCodeMode = service.CodeGenCounter
+
+	// StorageMongo and StorageRedis are the values accepted by the STORAGE env var.
+	StorageMongo = "mongo"
+	StorageRedis = "redis"
+
+	defaultRedisAddr = "localhost:6379"
+	cacheLRUCapacity = 1024
+
+	// defaultRoutingStrategy is the handler.RoutingStrategy used when ROUTING_STRATEGY is
+	// unset; it's handler.RoutingPathPrefix since it needs no DNS/wildcard-cert setup.
+	defaultRoutingStrategy = handler.RoutingPathPrefix
 )
 
-// ensureIndexes sets up necessary indexes for fast lookups and constraint enforcement.
-func ensureIndexes(ctx context.Context, client *mongo.Client) {
-	collection := client.Database(DBName).Collection(collectionName)
-	
-	// Index 1: Unique index on ShortCode (crucial for quick lookups and uniqueness check)
-	shortCodeIndex := mongo.IndexModel{
-		Keys:    bson.D{{Key: "shortCode", Value: 1}},
-		Options: options.Index().SetUnique(true),
+// getEnv returns the value of key, or fallback if it's unset or empty.
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return fallback
+}
+
+// newRepository builds the domain.Repository for the configured STORAGE backend. For
+// StorageMongo, redirects are optionally fronted by a CachingRepository when REDIS_ADDR is
+// set; without it, redirects read straight from Mongo, so the cache stays off by default for
+// anyone not running Redis.
+func newRepository(ctx context.Context, storageBackend string) (domain.Repository, *mongo.Client) {
+	switch storageBackend {
+	case StorageRedis:
+		redisClient := redis.NewClient(&redis.Options{Addr: getEnv("REDIS_ADDR", defaultRedisAddr)})
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		log.Println("Successfully connected to Redis!")
+		return repository.NewRedisRepository(redisClient), nil
+
+	default:
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(MongoURI))
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		if err = client.Ping(ctx, nil); err != nil {
+			log.Fatalf("Failed to ping MongoDB: %v", err)
+		}
+		log.Println("Successfully connected to MongoDB!")
+
+		// Run pending schema migrations; refuse to serve traffic if one fails rather than run
+		// against a partially migrated schema.
+		if err := migrations.Migrate(ctx, client, DBName); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		mongoRepo := repository.NewMongoRepository(client, DBName)
+
+		if cacheAddr := getEnv("REDIS_ADDR", ""); cacheAddr != "" {
+			cacheClient := redis.NewClient(&redis.Options{Addr: cacheAddr})
+			if err := cacheClient.Ping(ctx).Err(); err != nil {
+				log.Fatalf("Failed to connect to Redis cache: %v", err)
+			}
+			log.Println("Fronting MongoDB with a Redis/LRU cache for redirects")
+			return repository.NewCachingRepository(mongoRepo, cacheClient, cacheLRUCapacity), client
+		}
 
-	// Index 2: Index on original URL (crucial for quickly checking if a URL is already shortened)
-	urlIndex := mongo.IndexModel{
-		Keys:    bson.D{{Key: "url", Value: 1}},
+		return mongoRepo, client
 	}
-	
-	// Create the indexes
-	names, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{shortCodeIndex, urlIndex})
+}
+
+// newTenantRepository connects to MongoDB for the tenants collection. Tenant accounts live
+// in Mongo regardless of STORAGE, since they're low-volume reference data rather than
+// something the Redis hot path needs to touch.
+func newTenantRepository(ctx context.Context) (domain.TenantRepository, *mongo.Client) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(MongoURI))
 	if err != nil {
-		log.Fatalf("Failed to create indexes: %v", err)
+		log.Fatalf("Failed to connect to MongoDB for tenants: %v", err)
 	}
-	log.Printf("Successfully created indexes: %v", names)
+	if err = client.Ping(ctx, nil); err != nil {
+		log.Fatalf("Failed to ping MongoDB for tenants: %v", err)
+	}
+	return repository.NewMongoTenantRepository(client, DBName), client
 }
 
 func main() {
-	// 1. Initialize MongoDB Client
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(MongoURI))
-	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
-	}
+	// 1. Initialize the storage backend (STORAGE=mongo|redis, defaults to mongo)
+	repo, mongoClient := newRepository(ctx, getEnv("STORAGE", StorageMongo))
 
-	// Ping the primary to verify connection
-	if err = client.Ping(ctx, nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+	// 1b. Tenants (API-key auth, rate limits, quotas) always live in Mongo.
+	var tenants domain.TenantRepository
+	var tenantsClient *mongo.Client
+	if mongoClient != nil {
+		tenants = repository.NewMongoTenantRepository(mongoClient, DBName)
+	} else {
+		tenants, tenantsClient = newTenantRepository(ctx)
 	}
-	log.Println("Successfully connected to MongoDB!")
-
-	// 2. Ensure Database Indexes are present
-	ensureIndexes(ctx, client)
 
-	// 3. Initialize Layers
-	repo := repository.NewMongoRepository(client, DBName)
-	svc := service.NewShortenerService(repo)
-	h := handler.NewHandler(svc)
+	// 2. Initialize Layers
+	// No GeoIP resolver is wired up yet, so click events are recorded without a country.
+	svc := service.NewShortenerService(repo, ShortCodeMode, nil)
+	h := handler.NewHandler(svc, tenants, getEnv("ROUTING_STRATEGY", defaultRoutingStrategy))
 
-	// 4. Start Server
+	// 3. Start Server
 	router := h.Router()
-	
+
 	log.Printf("Starting URL Shortener API on http://localhost%s", Port)
 	log.Fatal(http.ListenAndServe(Port, router))
 
 	// Graceful shutdown (optional, but good practice)
 	defer func() {
-		if err = client.Disconnect(context.Background()); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
+		if mongoClient != nil {
+			if err := mongoClient.Disconnect(context.Background()); err != nil {
+				log.Printf("Error disconnecting from MongoDB: %v", err)
+			}
+		}
+		if tenantsClient != nil {
+			if err := tenantsClient.Disconnect(context.Background()); err != nil {
+				log.Printf("Error disconnecting tenants MongoDB client: %v", err)
+			}
 		}
 	}()
 }
-
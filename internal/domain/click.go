@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+)
+
+// ClickEvent records a single Redirect hit against a short code for analytics.
+type ClickEvent struct {
+	TenantID       string    `json:"tenantId" bson:"tenantID"`
+	ShortCode      string    `json:"shortCode" bson:"shortCode"`
+	Timestamp      time.Time `json:"timestamp" bson:"timestamp"`
+	Referrer       string    `json:"referrer,omitempty" bson:"referrer,omitempty"`
+	UserAgent      string    `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	Browser        string    `json:"browser,omitempty" bson:"browser,omitempty"`
+	OS             string    `json:"os,omitempty" bson:"os,omitempty"`
+	Country        string    `json:"country,omitempty" bson:"country,omitempty"`
+	AcceptLanguage string    `json:"acceptLanguage,omitempty" bson:"acceptLanguage,omitempty"`
+}
+
+// ClickBucket is a single point in a GetStats time-series response.
+type ClickBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// RedirectMeta carries the request details service.Redirect needs to record a ClickEvent,
+// without coupling the domain/service layers to net/http.
+type RedirectMeta struct {
+	Referrer       string
+	UserAgent      string
+	IP             string
+	AcceptLanguage string
+}
+
+// GeoIPResolver resolves a request IP to a country code. Implementations can be backed by
+// a local MaxMind database, an external API, or (in tests) a static map; a nil resolver
+// means country is left blank.
+type GeoIPResolver interface {
+	Country(ip string) (string, error)
+}
@@ -4,4 +4,9 @@ var (
 	ErrNotFound = "Short code not found"
 	ErrInvalidURL = "Invalid URL provided. Must be a valid http or https link."
 	ErrConflict = "URL already shortened"
+	ErrInvalidAlias = "Alias does not match the allowed charset or length"
+	ErrReservedAlias = "Alias is reserved and cannot be used"
+	ErrUnauthorized = "Missing or invalid API key"
+	ErrQuotaExceeded = "Monthly quota exceeded"
+	ErrRateLimited = "Rate limit exceeded"
 )
\ No newline at end of file
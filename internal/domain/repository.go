@@ -2,19 +2,38 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type Repository interface {
-	// FindByShortCode retrieves a URL document by its short code.
-	FindByShortCode(ctx context.Context, shortCode string) (URL, error)
-	// FindByOriginalURL retrieves a URL document by its original long URL.
-	FindByOriginalURL(ctx context.Context, originalURL string) (URL, error)
-	// Save creates a new URL document.
+	// FindByShortCode retrieves a URL document by tenant and short code.
+	FindByShortCode(ctx context.Context, tenantID, shortCode string) (URL, error)
+	// FindByOriginalURL retrieves a URL document by tenant and original long URL.
+	FindByOriginalURL(ctx context.Context, tenantID, originalURL string) (URL, error)
+	// Save creates a new URL document under u.ShortCode, returning ErrConflict if that code
+	// is already taken; picking a new code and retrying is left to the caller.
+	// u.TenantID scopes the write.
 	Save(ctx context.Context, u URL) (URL, error)
-	// Update updates the long URL for an existing document.
-	Update(ctx context.Context, shortCode string, newURL string) (URL, error)
-	// IncrementAccessCount increments the access count for a short code.
-	IncrementAccessCount(ctx context.Context, shortCode string) error
-	// Delete removes a URL document by its short code.
-	Delete(ctx context.Context, shortCode string) error
-}
\ No newline at end of file
+	// SaveWithAlias creates a new URL document for a user-supplied short code. Unlike Save,
+	// a duplicate-key error here means the alias is taken within u.TenantID and must not be
+	// retried with a different code.
+	SaveWithAlias(ctx context.Context, u URL) (URL, error)
+	// Update updates the long URL for an existing document scoped to tenantID.
+	Update(ctx context.Context, tenantID, shortCode string, newURL string) (URL, error)
+	// IncrementAccessCount increments the access count for a tenant's short code.
+	IncrementAccessCount(ctx context.Context, tenantID, shortCode string) error
+	// Delete removes a URL document by tenant and short code.
+	Delete(ctx context.Context, tenantID, shortCode string) error
+	// NextSequence atomically increments and returns the next value of the short-code
+	// counter. The sequence is shared across tenants so generated codes never collide
+	// regardless of which tenant claims them.
+	NextSequence(ctx context.Context) (int64, error)
+	// RecordClick persists a single click event.
+	RecordClick(ctx context.Context, tenantID, shortCode string, event ClickEvent) error
+	// RecordClicks persists a batch of click events in one write; used by the async
+	// click-event worker so redirects never wait on an analytics write.
+	RecordClicks(ctx context.Context, events []ClickEvent) error
+	// AggregateClicks groups the click events for a tenant's short code between from and to
+	// into a time series, bucketed by groupBy ("day", "hour", "country", or "referrer").
+	AggregateClicks(ctx context.Context, tenantID, shortCode string, from, to time.Time, groupBy string) ([]ClickBucket, error)
+}
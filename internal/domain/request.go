@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"time"
+)
+
+// CreateRequest is the payload accepted by POST /shorten.
+type CreateRequest struct {
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// UpdateRequest is the payload accepted by PUT /shorten/{code}.
+type UpdateRequest struct {
+	URL string `json:"url"`
+}
@@ -2,13 +2,19 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type ShortenerService interface {
-	Create(ctx context.Context, longURL string) (URL, error)
-	Get(ctx context.Context, shortCode string) (URL, error)
-	Update(ctx context.Context, shortCode string, newURL string) (URL, error)
-	Delete(ctx context.Context, shortCode string) error
-	GetStats(ctx context.Context, shortCode string) (URL, error)
-	Redirect(ctx context.Context, shortCode string) (string, error) // Returns the long URL for redirection
-}
\ No newline at end of file
+	Create(ctx context.Context, tenantID string, req CreateRequest) (URL, error)
+	Get(ctx context.Context, tenantID, shortCode string) (URL, error)
+	Update(ctx context.Context, tenantID, shortCode string, newURL string) (URL, error)
+	Delete(ctx context.Context, tenantID, shortCode string) error
+	GetStats(ctx context.Context, tenantID, shortCode string) (URL, error)
+	// GetClickStats returns a click time series for tenantID's shortCode between from and
+	// to, bucketed by groupBy ("day", "hour", "country", or "referrer").
+	GetClickStats(ctx context.Context, tenantID, shortCode string, from, to time.Time, groupBy string) ([]ClickBucket, error)
+	// Redirect returns the long URL for redirection. tenantID is resolved from the request
+	// route (subdomain or path prefix), not from an API key, since /s/{code} is public.
+	Redirect(ctx context.Context, tenantID, shortCode string, meta RedirectMeta) (string, error)
+}
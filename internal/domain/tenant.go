@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"time"
+)
+
+// Tenant is an API consumer authenticated via a bearer API key, with its own rate limit
+// and monthly quota on Create.
+type Tenant struct {
+	ID             string    `json:"id" bson:"_id"`
+	Name           string    `json:"name" bson:"name"`
+	APIKeyHash     string    `json:"-" bson:"apiKeyHash"`
+	RateLimitRPS   float64   `json:"rateLimitRps" bson:"rateLimitRps"`
+	MonthlyQuota   int64     `json:"monthlyQuota" bson:"monthlyQuota"`
+	UsagePeriod    string    `json:"-" bson:"usagePeriod"` // "2006-01" the month UsageThisMonth applies to
+	UsageThisMonth int64     `json:"usageThisMonth" bson:"usageThisMonth"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"context"
+)
+
+// TenantRepository manages tenant accounts used for API-key authentication and quota
+// enforcement. It's kept separate from Repository since tenants aren't short URLs.
+type TenantRepository interface {
+	// FindByAPIKeyHash looks up a tenant by the SHA-256 hash of its API key.
+	FindByAPIKeyHash(ctx context.Context, apiKeyHash string) (Tenant, error)
+	// IncrementUsage atomically increments a tenant's usage counter for period (a "2006-01"
+	// month key), resetting it to 1 if period has rolled over since the last increment, and
+	// returns the new count for that period.
+	IncrementUsage(ctx context.Context, tenantID string, period string) (int64, error)
+}
@@ -6,9 +6,11 @@ import (
 
 type URL struct{
 	ID			string		`json:"id" bson:"_id.omitempty"`
+	TenantID	string		`json:"tenantId" bson:"tenantID"`
 	URL			string		`json:"url" bson:"url"`
 	ShortCode	string		`json:"shortcode" bson:"shortCode"`
 	CreatedAt	time.Time	`json:"createdAt" bson:"createdAt"`
 	UpdatedAt	time.Time	`json:"updatedAt" bson:"updatedAt"`
 	AccessCount	int64		`json:"accessCount" bson:"accessCount"`
+	ExpiresAt	*time.Time	`json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
 }
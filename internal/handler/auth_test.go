@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitly/internal/domain"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	a := hashAPIKey("secret-key")
+	b := hashAPIKey("secret-key")
+	if a != b {
+		t.Fatalf("hashAPIKey is not deterministic: %q != %q", a, b)
+	}
+	if a == "secret-key" {
+		t.Fatal("hashAPIKey returned the input unhashed")
+	}
+	if hashAPIKey("other-key") == a {
+		t.Fatal("hashAPIKey produced the same hash for two different keys")
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	if _, ok := tenantFromContext(context.Background()); ok {
+		t.Fatal("expected no tenant in a bare context")
+	}
+
+	tenant := domain.Tenant{ID: "t1"}
+	ctx := context.WithValue(context.Background(), tenantContextKey, tenant)
+	got, ok := tenantFromContext(ctx)
+	if !ok || got.ID != tenant.ID {
+		t.Fatalf("tenantFromContext = %+v, %v, want %+v, true", got, ok, tenant)
+	}
+}
+
+func TestQuotaAlreadyExceeded(t *testing.T) {
+	period := time.Now().Format("2006-01")
+
+	tests := []struct {
+		name   string
+		tenant domain.Tenant
+		want   bool
+	}{
+		{
+			name:   "unlimited quota",
+			tenant: domain.Tenant{MonthlyQuota: 0, UsagePeriod: period, UsageThisMonth: 1000},
+			want:   false,
+		},
+		{
+			name:   "under quota this period",
+			tenant: domain.Tenant{MonthlyQuota: 10, UsagePeriod: period, UsageThisMonth: 5},
+			want:   false,
+		},
+		{
+			name:   "at quota this period",
+			tenant: domain.Tenant{MonthlyQuota: 10, UsagePeriod: period, UsageThisMonth: 10},
+			want:   true,
+		},
+		{
+			name:   "over quota this period",
+			tenant: domain.Tenant{MonthlyQuota: 10, UsagePeriod: period, UsageThisMonth: 11},
+			want:   true,
+		},
+		{
+			name:   "over quota but for a stale prior period",
+			tenant: domain.Tenant{MonthlyQuota: 10, UsagePeriod: "2000-01", UsageThisMonth: 999},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotaAlreadyExceeded(tt.tenant); got != tt.want {
+				t.Errorf("quotaAlreadyExceeded(%+v) = %v, want %v", tt.tenant, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenBucketAllow(t *testing.T) {
+	b := newTokenBucket(2) // capacity 2, refills at 2/sec
+
+	if !b.Allow() {
+		t.Fatal("expected first request to be allowed")
+	}
+	if !b.Allow() {
+		t.Fatal("expected second request to be allowed (capacity 2)")
+	}
+	if b.Allow() {
+		t.Fatal("expected third immediate request to be rate limited")
+	}
+
+	b.last = b.last.Add(-time.Second) // simulate a second elapsing without sleeping
+	if !b.Allow() {
+		t.Fatal("expected a request to be allowed after the bucket refills")
+	}
+}
+
+func TestTokenBucketAllowMinimumCapacityOne(t *testing.T) {
+	b := newTokenBucket(0)
+	if !b.Allow() {
+		t.Fatal("expected a zero-rps tenant to still get one token of burst capacity")
+	}
+	if b.Allow() {
+		t.Fatal("expected the second immediate request to be rate limited")
+	}
+}
+
+func TestRateLimiterAllowIsPerTenant(t *testing.T) {
+	rl := newRateLimiter()
+
+	if !rl.Allow("tenant-a", 1) {
+		t.Fatal("expected tenant-a's first request to be allowed")
+	}
+	if rl.Allow("tenant-a", 1) {
+		t.Fatal("expected tenant-a's second immediate request to be rate limited")
+	}
+	if !rl.Allow("tenant-b", 1) {
+		t.Fatal("tenant-b should have its own bucket, independent of tenant-a")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -18,15 +19,33 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// RoutingSubdomain and RoutingPathPrefix select how the public redirect endpoint resolves a
+// request's tenant, since /s/{code} has no API key to look one up with.
+const (
+	RoutingSubdomain  = "subdomain"
+	RoutingPathPrefix = "path"
+)
+
 // Handler holds the service dependency and implements HTTP handlers.
 type Handler struct {
 	Service domain.ShortenerService
+	Tenants domain.TenantRepository
+
+	// RoutingStrategy is RoutingSubdomain ("{tenantID}.host/s/{code}") or RoutingPathPrefix
+	// ("/{tenantID}/s/{code}"), selecting how Router wires the redirect route.
+	RoutingStrategy string
+
+	rateLimiter *rateLimiter
 }
 
-// NewHandler creates a new Handler instance.
-func NewHandler(service domain.ShortenerService) *Handler {
+// NewHandler creates a new Handler instance. routingStrategy must be RoutingSubdomain or
+// RoutingPathPrefix.
+func NewHandler(service domain.ShortenerService, tenants domain.TenantRepository, routingStrategy string) *Handler {
 	return &Handler{
-		Service: service,
+		Service:         service,
+		Tenants:         tenants,
+		RoutingStrategy: routingStrategy,
+		rateLimiter:     newRateLimiter(),
 	}
 }
 
@@ -68,20 +87,45 @@ func (h *Handler) commonMiddleware(next http.Handler) http.Handler {
 
 // CreateShortURL handles POST /shorten
 func (h *Handler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := tenantFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, domain.ErrUnauthorized)
+		return
+	}
+
+	// Cheap pre-check against the quota snapshot taken at auth time, so an already-exhausted
+	// tenant is rejected before doing any work instead of after a create-then-delete round
+	// trip; checkQuota below still re-checks authoritatively once Create succeeds.
+	if quotaAlreadyExceeded(tenant) {
+		respondWithError(w, http.StatusTooManyRequests, domain.ErrQuotaExceeded)
+		return
+	}
+
 	var req domain.CreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid JSON payload")
 		return
 	}
 
-	url, err := h.Service.Create(r.Context(), req.URL)
+	url, err := h.Service.Create(r.Context(), tenant.ID, req)
 
 	if err != nil {
-		if strings.Contains(err.Error(), domain.ErrInvalidURL) {
-			respondWithError(w, http.StatusBadRequest, domain.ErrInvalidURL)
+		if strings.Contains(err.Error(), domain.ErrInvalidURL) || strings.Contains(err.Error(), domain.ErrInvalidAlias) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if strings.Contains(err.Error(), domain.ErrReservedAlias) {
+			respondWithError(w, http.StatusUnavailableForLegalReasons, domain.ErrReservedAlias)
 			return
 		}
 		if strings.Contains(err.Error(), domain.ErrConflict) {
+			// The no-alias path returns the pre-existing URL document alongside ErrConflict;
+			// the alias path has no such document (the alias itself collided), so fall back
+			// to an error message rather than an empty body.
+			if url.ShortCode == "" {
+				respondWithError(w, http.StatusConflict, domain.ErrConflict)
+				return
+			}
 			respondWithJSON(w, http.StatusConflict, url)
 			return
 		}
@@ -89,15 +133,34 @@ func (h *Handler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only count a link against the tenant's monthly quota once Create has actually
+	// succeeded; a malformed or conflicting request shouldn't burn a unit of quota.
+	if err := h.checkQuota(r.Context(), tenant); err != nil {
+		if strings.Contains(err.Error(), domain.ErrQuotaExceeded) {
+			// Over quota for the period: roll back the link we just created rather than
+			// leave it live while reporting the request as rejected.
+			_ = h.Service.Delete(r.Context(), tenant.ID, url.ShortCode)
+			respondWithError(w, http.StatusTooManyRequests, domain.ErrQuotaExceeded)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
 	respondWithJSON(w, http.StatusCreated, url)
 }
 
 // GetURL handles GET /shorten/{code}
 func (h *Handler) GetURL(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := tenantFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, domain.ErrUnauthorized)
+		return
+	}
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
 
-	url, err := h.Service.Get(r.Context(), shortCode)
+	url, err := h.Service.Get(r.Context(), tenant.ID, shortCode)
 
 	if err != nil {
 		if strings.Contains(err.Error(), domain.ErrNotFound) {
@@ -113,6 +176,11 @@ func (h *Handler) GetURL(w http.ResponseWriter, r *http.Request) {
 
 // UpdateURL handles PUT /shorten/{code}
 func (h *Handler) UpdateURL(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := tenantFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, domain.ErrUnauthorized)
+		return
+	}
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
 
@@ -122,7 +190,7 @@ func (h *Handler) UpdateURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, err := h.Service.Update(r.Context(), shortCode, req.URL)
+	url, err := h.Service.Update(r.Context(), tenant.ID, shortCode, req.URL)
 
 	if err != nil {
 		if strings.Contains(err.Error(), domain.ErrInvalidURL) {
@@ -142,10 +210,15 @@ func (h *Handler) UpdateURL(w http.ResponseWriter, r *http.Request) {
 
 // DeleteURL handles DELETE /shorten/{code}
 func (h *Handler) DeleteURL(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := tenantFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, domain.ErrUnauthorized)
+		return
+	}
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
 
-	err := h.Service.Delete(r.Context(), shortCode)
+	err := h.Service.Delete(r.Context(), tenant.ID, shortCode)
 
 	if err != nil {
 		if strings.Contains(err.Error(), domain.ErrNotFound) {
@@ -159,13 +232,53 @@ func (h *Handler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// GetStats handles GET /shorten/{code}/stats
+// validGroupBy are the values accepted by the stats endpoint's groupBy query param.
+var validGroupBy = map[string]bool{"day": true, "hour": true, "country": true, "referrer": true}
+
+// GetStats handles GET /shorten/{code}/stats. Without a groupBy query param it returns the
+// URL document as before; with one, it returns a click time series between from/to
+// (RFC3339, defaulting to the last 30 days) bucketed by groupBy.
 func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := tenantFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, domain.ErrUnauthorized)
+		return
+	}
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
 
-	url, err := h.Service.GetStats(r.Context(), shortCode)
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy == "" {
+		url, err := h.Service.GetStats(r.Context(), tenant.ID, shortCode)
+		if err != nil {
+			if strings.Contains(err.Error(), domain.ErrNotFound) {
+				respondWithError(w, http.StatusNotFound, domain.ErrNotFound)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, url)
+		return
+	}
+
+	if !validGroupBy[groupBy] {
+		respondWithError(w, http.StatusBadRequest, "groupBy must be one of day, hour, country, referrer")
+		return
+	}
+
+	from, err := parseStatsTime(r.URL.Query().Get("from"), time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid 'from' timestamp, expected RFC3339")
+		return
+	}
+	to, err := parseStatsTime(r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid 'to' timestamp, expected RFC3339")
+		return
+	}
 
+	buckets, err := h.Service.GetClickStats(r.Context(), tenant.ID, shortCode, from, to, groupBy)
 	if err != nil {
 		if strings.Contains(err.Error(), domain.ErrNotFound) {
 			respondWithError(w, http.StatusNotFound, domain.ErrNotFound)
@@ -175,15 +288,33 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, url)
+	respondWithJSON(w, http.StatusOK, buckets)
+}
+
+// parseStatsTime parses an RFC3339 query param, returning fallback when v is empty.
+func parseStatsTime(v string, fallback time.Time) (time.Time, error) {
+	if v == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.RFC3339, v)
 }
 
-// Redirect handles GET /s/{code}
+// Redirect handles GET /s/{code}. Unlike the /shorten API, it's public and carries no API
+// key, so tenantID is resolved from the route itself (subdomain or path prefix, per
+// h.RoutingStrategy) rather than from tenantAuthMiddleware.
 func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	shortCode := vars["shortCode"]
+	tenantID := vars["tenantID"]
 
-	longURL, err := h.Service.Redirect(r.Context(), shortCode)
+	meta := domain.RedirectMeta{
+		Referrer:       r.Referer(),
+		UserAgent:      r.UserAgent(),
+		IP:             clientIP(r),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+	}
+
+	longURL, err := h.Service.Redirect(r.Context(), tenantID, shortCode, meta)
 
 	if err != nil {
 		if strings.Contains(err.Error(), domain.ErrNotFound) {
@@ -197,21 +328,41 @@ func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, longURL, http.StatusTemporaryRedirect) // 307
 }
 
+// clientIP extracts the originating client IP, preferring X-Forwarded-For (set by a
+// reverse proxy) over RemoteAddr.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // Router sets up all the application routes.
 func (h *Handler) Router() *mux.Router {
 	r := mux.NewRouter()
-	
-	// Apply common middleware to all API endpoints
+
+	// Apply common middleware plus API-key auth and per-tenant rate limiting to all API
+	// endpoints.
 	apiRouter := r.PathPrefix("/shorten").Subrouter()
-	apiRouter.Use(h.commonMiddleware)
+	apiRouter.Use(h.commonMiddleware, h.tenantAuthMiddleware)
 
 	// API Endpoints (CRUD and Stats)
 	apiRouter.HandleFunc("", h.CreateShortURL).Methods("POST")
 	apiRouter.HandleFunc("/{shortCode}", h.GetURL).Methods("GET", "PUT", "DELETE")
 	apiRouter.HandleFunc("/{shortCode}/stats", h.GetStats).Methods("GET")
 
-	// Redirection Endpoint (No middleware applied to keep it fast)
-	r.HandleFunc("/s/{shortCode}", h.Redirect).Methods("GET")
-	
+	// Redirection Endpoint (No auth applied to keep it fast and public). tenantID comes from
+	// the route itself, shaped by h.RoutingStrategy.
+	switch h.RoutingStrategy {
+	case RoutingSubdomain:
+		r.Host("{tenantID}.{domain:.+}").Path("/s/{shortCode}").HandlerFunc(h.Redirect).Methods("GET")
+	default:
+		r.HandleFunc("/{tenantID}/s/{shortCode}", h.Redirect).Methods("GET")
+	}
+
 	return r
 }
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"bitly/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cacheRedisKeyPrefix = "cache:code:"
+	cacheRedisTTL       = 10 * time.Minute
+)
+
+// lruCache is a minimal fixed-capacity, thread-safe LRU cache of domain.URL keyed by short
+// code. It exists only to take the hottest codes off the Redis round trip entirely.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front = most recently used
+}
+
+type lruEntry struct {
+	key string
+	url domain.URL
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) (domain.URL, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return domain.URL{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).url, true
+}
+
+func (c *lruCache) set(key string, url domain.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruEntry).url = url
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&lruEntry{key: key, url: url})
+	c.entries[key] = el
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*lruEntry).key)
+		}
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// CachingRepository decorates any domain.Repository with an in-memory LRU fronted by a
+// Redis cache for FindByShortCode, the redirect hot path. Writes are forwarded to the
+// backend and the cache entry for the affected code is dropped rather than refreshed, so a
+// stale read after a write is at worst a single cache miss.
+type CachingRepository struct {
+	backend domain.Repository
+	redis   *redis.Client
+	lru     *lruCache
+}
+
+// NewCachingRepository wraps backend with an LRU (lruCapacity entries) plus a Redis cache
+// for FindByShortCode lookups.
+func NewCachingRepository(backend domain.Repository, redisClient *redis.Client, lruCapacity int) *CachingRepository {
+	return &CachingRepository{
+		backend: backend,
+		redis:   redisClient,
+		lru:     newLRUCache(lruCapacity),
+	}
+}
+
+// cacheKey namespaces a cache entry by tenant so two tenants' codes never collide in the LRU
+// or Redis cache.
+func cacheKey(tenantID, shortCode string) string {
+	return tenantID + ":" + shortCode
+}
+
+// FindByShortCode checks the in-memory LRU, then Redis, before falling back to the backend
+// repository. A backend hit is written through to both caches. The full domain.URL is
+// cached (JSON-encoded) rather than just the long URL, since Get and GetStats share this
+// same lookup with Redirect and need CreatedAt/UpdatedAt/AccessCount/ExpiresAt too.
+func (c *CachingRepository) FindByShortCode(ctx context.Context, tenantID, shortCode string) (domain.URL, error) {
+	key := cacheKey(tenantID, shortCode)
+	if u, ok := c.lru.get(key); ok {
+		return u, nil
+	}
+
+	if cached, err := c.redis.Get(ctx, cacheRedisKeyPrefix+key).Result(); err == nil {
+		var u domain.URL
+		if err := json.Unmarshal([]byte(cached), &u); err == nil {
+			c.lru.set(key, u)
+			return u, nil
+		}
+	}
+
+	u, err := c.backend.FindByShortCode(ctx, tenantID, shortCode)
+	if err != nil {
+		return domain.URL{}, err
+	}
+
+	c.lru.set(key, u)
+	if encoded, err := json.Marshal(u); err == nil {
+		c.redis.Set(ctx, cacheRedisKeyPrefix+key, encoded, cacheRedisTTL)
+	}
+
+	return u, nil
+}
+
+func (c *CachingRepository) invalidate(ctx context.Context, tenantID, shortCode string) {
+	key := cacheKey(tenantID, shortCode)
+	c.lru.remove(key)
+	c.redis.Del(ctx, cacheRedisKeyPrefix+key)
+}
+
+func (c *CachingRepository) FindByOriginalURL(ctx context.Context, tenantID, originalURL string) (domain.URL, error) {
+	return c.backend.FindByOriginalURL(ctx, tenantID, originalURL)
+}
+
+func (c *CachingRepository) Save(ctx context.Context, u domain.URL) (domain.URL, error) {
+	return c.backend.Save(ctx, u)
+}
+
+func (c *CachingRepository) SaveWithAlias(ctx context.Context, u domain.URL) (domain.URL, error) {
+	return c.backend.SaveWithAlias(ctx, u)
+}
+
+func (c *CachingRepository) Update(ctx context.Context, tenantID, shortCode string, newURL string) (domain.URL, error) {
+	u, err := c.backend.Update(ctx, tenantID, shortCode, newURL)
+	if err == nil {
+		c.invalidate(ctx, tenantID, shortCode)
+	}
+	return u, err
+}
+
+func (c *CachingRepository) IncrementAccessCount(ctx context.Context, tenantID, shortCode string) error {
+	err := c.backend.IncrementAccessCount(ctx, tenantID, shortCode)
+	if err == nil {
+		// The cached AccessCount would otherwise go stale for as long as the entry stays
+		// resident; drop it so the next FindByShortCode (GetStats, Get) re-reads the backend.
+		c.invalidate(ctx, tenantID, shortCode)
+	}
+	return err
+}
+
+func (c *CachingRepository) Delete(ctx context.Context, tenantID, shortCode string) error {
+	err := c.backend.Delete(ctx, tenantID, shortCode)
+	if err == nil {
+		c.invalidate(ctx, tenantID, shortCode)
+	}
+	return err
+}
+
+func (c *CachingRepository) NextSequence(ctx context.Context) (int64, error) {
+	return c.backend.NextSequence(ctx)
+}
+
+func (c *CachingRepository) RecordClick(ctx context.Context, tenantID, shortCode string, event domain.ClickEvent) error {
+	return c.backend.RecordClick(ctx, tenantID, shortCode, event)
+}
+
+func (c *CachingRepository) RecordClicks(ctx context.Context, events []domain.ClickEvent) error {
+	return c.backend.RecordClicks(ctx, events)
+}
+
+func (c *CachingRepository) AggregateClicks(ctx context.Context, tenantID, shortCode string, from, to time.Time, groupBy string) ([]domain.ClickBucket, error) {
+	return c.backend.AggregateClicks(ctx, tenantID, shortCode, from, to, groupBy)
+}
+
+var _ domain.Repository = (*CachingRepository)(nil)
+var _ domain.Repository = (*RedisRepository)(nil)
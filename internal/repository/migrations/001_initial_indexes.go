@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// initialIndexes creates the shortCode uniqueness constraint and the url lookup index that
+// main.go's ensureIndexes used to set up by hand at startup.
+type initialIndexes struct{}
+
+func (initialIndexes) Version() string { return "1.0.0" }
+
+func (initialIndexes) Up(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("urls")
+
+	shortCodeIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "shortCode", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	urlIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "url", Value: 1}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{shortCodeIndex, urlIndex})
+	return err
+}
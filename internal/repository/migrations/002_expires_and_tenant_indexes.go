@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// expiresAndTenantIndexes adds a TTL index on expiresAt (so aliases created with an
+// ExpiresAt are automatically reaped) and a compound (tenantID, shortCode) index in
+// preparation for multi-tenancy, ahead of tenantID actually existing on URL documents.
+type expiresAndTenantIndexes struct{}
+
+func (expiresAndTenantIndexes) Version() string { return "2.0.0" }
+
+func (expiresAndTenantIndexes) Up(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("urls")
+
+	expiresAtIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	tenantShortCodeIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "tenantID", Value: 1}, {Key: "shortCode", Value: 1}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{expiresAtIndex, tenantShortCodeIndex})
+	return err
+}
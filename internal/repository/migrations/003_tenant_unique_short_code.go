@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tenantUniqueShortCode replaces the global shortCode uniqueness constraint from
+// initialIndexes with a unique compound (tenantID, shortCode) index, now that tenantID
+// exists on every URL document. This lets different tenants reuse the same slug. The
+// non-unique compound index created by expiresAndTenantIndexes is dropped first since Mongo
+// doesn't allow two indexes with the same key pattern to coexist.
+type tenantUniqueShortCode struct{}
+
+func (tenantUniqueShortCode) Version() string { return "3.0.0" }
+
+func (tenantUniqueShortCode) Up(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("urls")
+
+	if _, err := collection.Indexes().DropOne(ctx, "tenantID_1_shortCode_1"); err != nil {
+		// The index may not exist yet in a database that skipped straight to this version;
+		// that's fine, CreateOne below will still put the unique index in place.
+		if cmdErr, ok := err.(mongo.CommandError); !ok || cmdErr.Code != 27 {
+			return err
+		}
+	}
+
+	if _, err := collection.Indexes().DropOne(ctx, "shortCode_1"); err != nil {
+		if cmdErr, ok := err.(mongo.CommandError); !ok || cmdErr.Code != 27 {
+			return err
+		}
+	}
+
+	tenantShortCodeUniqueIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenantID", Value: 1}, {Key: "shortCode", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := collection.Indexes().CreateOne(ctx, tenantShortCodeUniqueIndex)
+	return err
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const migrationsCollectionName = "migrations"
+
+// appliedMigration records that a migration has run, in the "migrations" collection.
+type appliedMigration struct {
+	Version   string    `bson:"_id"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// Migrate runs every pending migration against dbName in order, recording each applied
+// version in the "migrations" collection. It stops on the first failure and returns an
+// error without recording that version as applied, so the caller can refuse to serve
+// traffic rather than run against a partially migrated schema.
+func Migrate(ctx context.Context, client *mongo.Client, dbName string) error {
+	db := client.Database(dbName)
+	collection := db.Collection(migrationsCollectionName)
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("migrations: failed to load applied versions: %w", err)
+	}
+	var rows []appliedMigration
+	if err := cursor.All(ctx, &rows); err != nil {
+		return fmt.Errorf("migrations: failed to decode applied versions: %w", err)
+	}
+
+	applied := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = true
+	}
+
+	for _, m := range All() {
+		if applied[m.Version()] {
+			continue
+		}
+
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migrations: %s failed: %w", m.Version(), err)
+		}
+
+		if _, err := collection.InsertOne(ctx, appliedMigration{Version: m.Version(), AppliedAt: time.Now()}); err != nil {
+			return fmt.Errorf("migrations: failed to record %s as applied: %w", m.Version(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration is a single, idempotent schema change applied to the database.
+type Migration interface {
+	// Version returns this migration's semantic version, e.g. "1.0.0".
+	Version() string
+	// Up applies the migration.
+	Up(ctx context.Context, db *mongo.Database) error
+}
+
+// All returns every migration in the order they must be applied.
+func All() []Migration {
+	return []Migration{
+		initialIndexes{},
+		expiresAndTenantIndexes{},
+		tenantUniqueShortCode{},
+	}
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -10,29 +11,40 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const collectionName = "urls"
+const (
+	collectionName         = "urls"
+	countersCollectionName = "counters"
+	urlCounterID           = "urls"
+	clicksCollectionName   = "clicks"
+	tenantsCollectionName  = "tenants"
+)
 
 // MongoRepository implements the domain.Repository interface using MongoDB.
 type MongoRepository struct {
 	Collection *mongo.Collection
+	Counters   *mongo.Collection
+	Clicks     *mongo.Collection
 }
 
 // NewMongoRepository creates a new repository instance.
 func NewMongoRepository(client *mongo.Client, dbName string) *MongoRepository {
-	collection := client.Database(dbName).Collection(collectionName)
+	db := client.Database(dbName)
 	return &MongoRepository{
-		Collection: collection,
+		Collection: db.Collection(collectionName),
+		Counters:   db.Collection(countersCollectionName),
+		Clicks:     db.Collection(clicksCollectionName),
 	}
 }
 
-// FindByShortCode retrieves a URL document by its short code.
-func (r *MongoRepository) FindByShortCode(ctx context.Context, shortCode string) (domain.URL, error) {
+// FindByShortCode retrieves a URL document by tenant and short code.
+func (r *MongoRepository) FindByShortCode(ctx context.Context, tenantID, shortCode string) (domain.URL, error) {
 	var u domain.URL
-	filter := bson.M{"shortCode": shortCode}
+	filter := bson.M{"tenantID": tenantID, "shortCode": shortCode}
 	err := r.Collection.FindOne(ctx, filter).Decode(&u)
-	
+
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return domain.URL{}, errors.New(domain.ErrNotFound)
@@ -42,12 +54,12 @@ func (r *MongoRepository) FindByShortCode(ctx context.Context, shortCode string)
 	return u, nil
 }
 
-// FindByOriginalURL retrieves a URL document by its original long URL.
-func (r *MongoRepository) FindByOriginalURL(ctx context.Context, originalURL string) (domain.URL, error) {
+// FindByOriginalURL retrieves a URL document by tenant and original long URL.
+func (r *MongoRepository) FindByOriginalURL(ctx context.Context, tenantID, originalURL string) (domain.URL, error) {
 	var u domain.URL
-	filter := bson.M{"url": originalURL}
+	filter := bson.M{"tenantID": tenantID, "url": originalURL}
 	err := r.Collection.FindOne(ctx, filter).Decode(&u)
-	
+
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return domain.URL{}, errors.New(domain.ErrNotFound)
@@ -78,43 +90,64 @@ func (r *MongoRepository) Save(ctx context.Context, u domain.URL) (domain.URL, e
 	return u, nil
 }
 
-// Update updates the long URL for an existing document.
-func (r *MongoRepository) Update(ctx context.Context, shortCode string, newURL string) (domain.URL, error) {
-	filter := bson.M{"shortCode": shortCode}
+// SaveWithAlias creates a new URL document for a user-supplied short code. A duplicate-key
+// error on shortCode is surfaced as ErrConflict just like Save, but the caller (service.Create)
+// treats it as terminal instead of retrying with a newly generated code.
+func (r *MongoRepository) SaveWithAlias(ctx context.Context, u domain.URL) (domain.URL, error) {
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = time.Now()
+
+	_, err := r.Collection.InsertOne(ctx, u)
+	if err != nil {
+		if writeErr, ok := err.(mongo.WriteException); ok {
+			for _, e := range writeErr.WriteErrors {
+				if e.Code == 11000 && strings.Contains(e.Message, "shortCode") {
+					return domain.URL{}, errors.New(domain.ErrConflict)
+				}
+			}
+		}
+		return domain.URL{}, err
+	}
+	return u, nil
+}
+
+// Update updates the long URL for an existing document scoped to tenantID.
+func (r *MongoRepository) Update(ctx context.Context, tenantID, shortCode string, newURL string) (domain.URL, error) {
+	filter := bson.M{"tenantID": tenantID, "shortCode": shortCode}
 	update := bson.M{
 		"$set": bson.M{
 			"url": newURL,
 			"updatedAt": time.Now(),
 		},
 	}
-	
+
 	var updatedURL domain.URL
-	
+
 	// Find the document, update it, and return the new version in one go
 	err := r.Collection.FindOneAndUpdate(ctx, filter, update).Decode(&updatedURL)
-	
+
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return domain.URL{}, errors.New(domain.ErrNotFound)
 		}
 		return domain.URL{}, err
 	}
-	
+
 	// The FindOneAndUpdate returned the *old* document before update. We need to query again or re-apply changes.
 	// Simpler approach: update the retrieved struct with the new values and return it (since the update was successful).
 	updatedURL.URL = newURL
 	updatedURL.UpdatedAt = time.Now() // It's actually the old timestamp, but we manually set the new one
-	
+
 	return updatedURL, nil
 }
 
-// IncrementAccessCount increments the access count for a short code.
-func (r *MongoRepository) IncrementAccessCount(ctx context.Context, shortCode string) error {
-	filter := bson.M{"shortCode": shortCode}
+// IncrementAccessCount increments the access count for a tenant's short code.
+func (r *MongoRepository) IncrementAccessCount(ctx context.Context, tenantID, shortCode string) error {
+	filter := bson.M{"tenantID": tenantID, "shortCode": shortCode}
 	update := bson.M{
 		"$inc": bson.M{"accessCount": 1},
 	}
-	
+
 	result, err := r.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
@@ -125,11 +158,108 @@ func (r *MongoRepository) IncrementAccessCount(ctx context.Context, shortCode st
 	return nil
 }
 
-// Delete removes a URL document by its short code.
-func (r *MongoRepository) Delete(ctx context.Context, shortCode string) error {
-	filter := bson.M{"shortCode": shortCode}
+// NextSequence atomically increments the "urls" counter document (creating it on first use)
+// and returns its new value.
+func (r *MongoRepository) NextSequence(ctx context.Context) (int64, error) {
+	filter := bson.M{"_id": urlCounterID}
+	update := bson.M{"$inc": bson.M{"seq": int64(1)}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := r.Counters.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter); err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// RecordClick persists a single click event.
+func (r *MongoRepository) RecordClick(ctx context.Context, tenantID, shortCode string, event domain.ClickEvent) error {
+	event.TenantID = tenantID
+	event.ShortCode = shortCode
+	_, err := r.Clicks.InsertOne(ctx, event)
+	return err
+}
+
+// RecordClicks persists a batch of click events in one write.
+func (r *MongoRepository) RecordClicks(ctx context.Context, events []domain.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(events))
+	for i, e := range events {
+		docs[i] = e
+	}
+	_, err := r.Clicks.InsertMany(ctx, docs)
+	return err
+}
+
+// clickGroupExpr maps a groupBy query param to the Mongo aggregation expression used for
+// the $group stage's _id. The "country"/"referrer" cases are bare field references
+// ("$country"), so the return type is interface{} rather than bson.M.
+func clickGroupExpr(groupBy string) (interface{}, error) {
+	switch groupBy {
+	case "day":
+		return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$timestamp"}}, nil
+	case "hour":
+		return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%dT%H:00", "date": "$timestamp"}}, nil
+	case "country":
+		return "$country", nil
+	case "referrer":
+		return "$referrer", nil
+	default:
+		return nil, fmt.Errorf("unsupported groupBy value: %s", groupBy)
+	}
+}
+
+// AggregateClicks groups the click events for a tenant's shortCode between from and to into
+// a time series via a $match + $group + $sort pipeline.
+func (r *MongoRepository) AggregateClicks(ctx context.Context, tenantID, shortCode string, from, to time.Time, groupBy string) ([]domain.ClickBucket, error) {
+	groupID, err := clickGroupExpr(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"tenantID":  tenantID,
+			"shortCode": shortCode,
+			"timestamp": bson.M{"$gte": from, "$lte": to},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   groupID,
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := r.Clicks.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID    string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]domain.ClickBucket, len(rows))
+	for i, row := range rows {
+		buckets[i] = domain.ClickBucket{Key: row.ID, Count: row.Count}
+	}
+	return buckets, nil
+}
+
+// Delete removes a URL document by tenant and short code.
+func (r *MongoRepository) Delete(ctx context.Context, tenantID, shortCode string) error {
+	filter := bson.M{"tenantID": tenantID, "shortCode": shortCode}
 	result, err := r.Collection.DeleteOne(ctx, filter)
-	
+
 	if err != nil {
 		return err
 	}
@@ -138,3 +268,71 @@ func (r *MongoRepository) Delete(ctx context.Context, shortCode string) error {
 	}
 	return nil
 }
+
+// MongoTenantRepository implements the domain.TenantRepository interface using MongoDB.
+type MongoTenantRepository struct {
+	Tenants *mongo.Collection
+}
+
+// NewMongoTenantRepository creates a new tenant repository instance.
+func NewMongoTenantRepository(client *mongo.Client, dbName string) *MongoTenantRepository {
+	return &MongoTenantRepository{
+		Tenants: client.Database(dbName).Collection(tenantsCollectionName),
+	}
+}
+
+// FindByAPIKeyHash retrieves a tenant by the SHA-256 hash of its API key.
+func (r *MongoTenantRepository) FindByAPIKeyHash(ctx context.Context, apiKeyHash string) (domain.Tenant, error) {
+	var t domain.Tenant
+	filter := bson.M{"apiKeyHash": apiKeyHash}
+	err := r.Tenants.FindOne(ctx, filter).Decode(&t)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.Tenant{}, errors.New(domain.ErrUnauthorized)
+		}
+		return domain.Tenant{}, err
+	}
+	return t, nil
+}
+
+// IncrementUsage atomically increments a tenant's usage counter for period, resetting it to 1
+// first if the tenant's stored usagePeriod doesn't match, and returns the new count. Each
+// FindOneAndUpdate below is atomic per document, so the two-step increment-or-rollover below
+// can't double-reset: if two requests race the rollover, only the one whose filter still
+// matches usagePeriod != period applies it, and the loser retries as a plain increment
+// against the now-rolled-over document.
+func (r *MongoTenantRepository) IncrementUsage(ctx context.Context, tenantID, period string) (int64, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		filter := bson.M{"_id": tenantID, "usagePeriod": period}
+		update := bson.M{"$inc": bson.M{"usageThisMonth": int64(1)}}
+
+		var tenant domain.Tenant
+		err := r.Tenants.FindOneAndUpdate(ctx, filter, update, opts).Decode(&tenant)
+		if err == nil {
+			return tenant.UsageThisMonth, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, err
+		}
+
+		// No document matched usagePeriod: either the tenant rolled over to a new period, or
+		// this is the first increment ever. Only roll over if usagePeriod still isn't period,
+		// so a second concurrent request that loses this race falls through to retry the
+		// plain increment above instead of resetting the counter a second time.
+		rolloverFilter := bson.M{"_id": tenantID, "usagePeriod": bson.M{"$ne": period}}
+		rolloverUpdate := bson.M{"$set": bson.M{"usagePeriod": period, "usageThisMonth": int64(1)}}
+		err = r.Tenants.FindOneAndUpdate(ctx, rolloverFilter, rolloverUpdate, opts).Decode(&tenant)
+		if err == nil {
+			return tenant.UsageThisMonth, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, err
+		}
+		// Lost the rollover race (or the tenant doesn't exist at all) — loop once more to
+		// either retry the plain increment or, on the second pass, report ErrUnauthorized.
+	}
+
+	return 0, errors.New(domain.ErrUnauthorized)
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"bitly/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisCodeKeyPrefix   = "code:" // code -> long URL
+	redisURLKeyPrefix    = "url:"  // long URL -> code (reverse index)
+	redisHitsKeySuffix   = ":hits"
+	redisURLCounterKey   = "urls:seq"
+	redisClicksKeyPrefix = "clicks:" // sorted set of click events per short code
+)
+
+// redisClickMember is the JSON payload stored as a sorted-set member in Redis, scored by
+// event timestamp (unix nanoseconds) so AggregateClicks can range-query by time.
+type redisClickMember struct {
+	Timestamp time.Time `json:"t"`
+	Referrer  string    `json:"r,omitempty"`
+	Country   string    `json:"c,omitempty"`
+}
+
+// RedisRepository implements domain.Repository backed by Redis. It trades the document
+// flexibility and query power of MongoRepository for much lower redirect latency, at the
+// cost of storing only what the hot path (Redirect) and CRUD flows actually need.
+type RedisRepository struct {
+	Client *redis.Client
+}
+
+// NewRedisRepository creates a new Redis-backed repository instance.
+func NewRedisRepository(client *redis.Client) *RedisRepository {
+	return &RedisRepository{Client: client}
+}
+
+// tenantCodeKey namespaces a short-code key by tenant so two tenants can claim the same code.
+func tenantCodeKey(tenantID, shortCode string) string {
+	return redisCodeKeyPrefix + tenantID + ":" + shortCode
+}
+
+// tenantURLKey namespaces the reverse (long URL -> code) index by tenant.
+func tenantURLKey(tenantID, originalURL string) string {
+	return redisURLKeyPrefix + tenantID + ":" + originalURL
+}
+
+// FindByShortCode retrieves a URL document by tenant and short code. The long URL and the
+// access count are fetched in a single pipelined round trip since both are needed by
+// GetStats and Redirect.
+func (r *RedisRepository) FindByShortCode(ctx context.Context, tenantID, shortCode string) (domain.URL, error) {
+	codeKey := tenantCodeKey(tenantID, shortCode)
+	pipe := r.Client.Pipeline()
+	urlCmd := pipe.Get(ctx, codeKey)
+	hitsCmd := pipe.Get(ctx, codeKey+redisHitsKeySuffix)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return domain.URL{}, err
+	}
+
+	longURL, err := urlCmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.URL{}, errors.New(domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.URL{}, err
+	}
+
+	accessCount, err := hitsCmd.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return domain.URL{}, err
+	}
+
+	return domain.URL{TenantID: tenantID, ShortCode: shortCode, URL: longURL, AccessCount: accessCount}, nil
+}
+
+// FindByOriginalURL retrieves a URL document by tenant and original long URL via the reverse
+// index.
+func (r *RedisRepository) FindByOriginalURL(ctx context.Context, tenantID, originalURL string) (domain.URL, error) {
+	shortCode, err := r.Client.Get(ctx, tenantURLKey(tenantID, originalURL)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.URL{}, errors.New(domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.URL{}, err
+	}
+	return r.FindByShortCode(ctx, tenantID, shortCode)
+}
+
+// redisTTL returns the Redis TTL to apply for expiresAt: 0 (no expiry) when expiresAt is
+// nil, matching the Mongo backend's TTL index on the expiresAt field.
+func redisTTL(expiresAt *time.Time) time.Duration {
+	if expiresAt == nil {
+		return 0
+	}
+	if ttl := time.Until(*expiresAt); ttl > 0 {
+		return ttl
+	}
+	// Already expired: still set a short-lived key rather than 0 (no expiry), so it doesn't
+	// linger forever if the caller races the expiry.
+	return time.Second
+}
+
+// Save creates a new URL document, rejecting the write if the short code is already taken
+// within u.TenantID. If u.ExpiresAt is set, both the code key and its reverse index expire
+// at that time, matching the Mongo backend's TTL index.
+func (r *RedisRepository) Save(ctx context.Context, u domain.URL) (domain.URL, error) {
+	ttl := redisTTL(u.ExpiresAt)
+
+	ok, err := r.Client.SetNX(ctx, tenantCodeKey(u.TenantID, u.ShortCode), u.URL, ttl).Result()
+	if err != nil {
+		return domain.URL{}, err
+	}
+	if !ok {
+		return domain.URL{}, errors.New(domain.ErrConflict)
+	}
+	if err := r.Client.Set(ctx, tenantURLKey(u.TenantID, u.URL), u.ShortCode, ttl).Err(); err != nil {
+		return domain.URL{}, err
+	}
+
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = time.Now()
+	return u, nil
+}
+
+// SaveWithAlias creates a new URL document for a user-supplied short code. The SetNX above
+// already distinguishes "alias taken" from any other failure, so the behavior is identical
+// to Save.
+func (r *RedisRepository) SaveWithAlias(ctx context.Context, u domain.URL) (domain.URL, error) {
+	return r.Save(ctx, u)
+}
+
+// Update updates the long URL for an existing document scoped to tenantID, keeping the
+// reverse index in sync.
+func (r *RedisRepository) Update(ctx context.Context, tenantID, shortCode string, newURL string) (domain.URL, error) {
+	codeKey := tenantCodeKey(tenantID, shortCode)
+	oldURL, err := r.Client.Get(ctx, codeKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.URL{}, errors.New(domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.URL{}, err
+	}
+
+	pipe := r.Client.Pipeline()
+	pipe.Set(ctx, codeKey, newURL, 0)
+	pipe.Set(ctx, tenantURLKey(tenantID, newURL), shortCode, 0)
+	pipe.Del(ctx, tenantURLKey(tenantID, oldURL))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return domain.URL{}, err
+	}
+
+	return domain.URL{TenantID: tenantID, ShortCode: shortCode, URL: newURL, UpdatedAt: time.Now()}, nil
+}
+
+// IncrementAccessCount increments the access count for a tenant's short code.
+func (r *RedisRepository) IncrementAccessCount(ctx context.Context, tenantID, shortCode string) error {
+	codeKey := tenantCodeKey(tenantID, shortCode)
+	exists, err := r.Client.Exists(ctx, codeKey).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return errors.New(domain.ErrNotFound)
+	}
+	return r.Client.Incr(ctx, codeKey+redisHitsKeySuffix).Err()
+}
+
+// Delete removes a URL document by tenant and short code, along with its reverse index and
+// hit counter entries.
+func (r *RedisRepository) Delete(ctx context.Context, tenantID, shortCode string) error {
+	codeKey := tenantCodeKey(tenantID, shortCode)
+	longURL, err := r.Client.Get(ctx, codeKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return errors.New(domain.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	pipe := r.Client.Pipeline()
+	pipe.Del(ctx, codeKey)
+	pipe.Del(ctx, tenantURLKey(tenantID, longURL))
+	pipe.Del(ctx, codeKey+redisHitsKeySuffix)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// NextSequence atomically increments the shared urls counter and returns its new value.
+func (r *RedisRepository) NextSequence(ctx context.Context) (int64, error) {
+	return r.Client.Incr(ctx, redisURLCounterKey).Result()
+}
+
+// tenantClicksKey namespaces the per-code click sorted set by tenant.
+func tenantClicksKey(tenantID, shortCode string) string {
+	return redisClicksKeyPrefix + tenantID + ":" + shortCode
+}
+
+// RecordClick persists a single click event into the per-code sorted set, scored by
+// timestamp. Redis only keeps the fields AggregateClicks actually groups by (day/hour come
+// from the timestamp stored in the member; the score is used only for the time range);
+// browser/OS/user-agent aren't retained in this backend.
+func (r *RedisRepository) RecordClick(ctx context.Context, tenantID, shortCode string, event domain.ClickEvent) error {
+	event.TenantID = tenantID
+	return r.addClickMember(ctx, tenantID, shortCode, event)
+}
+
+// RecordClicks persists a batch of click events, one ZADD per event pipelined into a single
+// round trip.
+func (r *RedisRepository) RecordClicks(ctx context.Context, events []domain.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	pipe := r.Client.Pipeline()
+	for _, event := range events {
+		member, err := encodeClickMember(event)
+		if err != nil {
+			return err
+		}
+		pipe.ZAdd(ctx, tenantClicksKey(event.TenantID, event.ShortCode), redis.Z{
+			Score:  float64(event.Timestamp.UnixNano()),
+			Member: member,
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func encodeClickMember(event domain.ClickEvent) (string, error) {
+	member := redisClickMember{Timestamp: event.Timestamp, Referrer: event.Referrer, Country: event.Country}
+	b, err := json.Marshal(member)
+	return string(b), err
+}
+
+func (r *RedisRepository) addClickMember(ctx context.Context, tenantID, shortCode string, event domain.ClickEvent) error {
+	event.ShortCode = shortCode
+	member, err := encodeClickMember(event)
+	if err != nil {
+		return err
+	}
+	return r.Client.ZAdd(ctx, tenantClicksKey(tenantID, shortCode), redis.Z{
+		Score:  float64(event.Timestamp.UnixNano()),
+		Member: member,
+	}).Err()
+}
+
+// AggregateClicks range-queries the per-code sorted set between from and to, then buckets
+// the results in memory by groupBy. This is a best-effort analogue of the Mongo aggregation
+// pipeline, not a substitute for it at high event volumes.
+func (r *RedisRepository) AggregateClicks(ctx context.Context, tenantID, shortCode string, from, to time.Time, groupBy string) ([]domain.ClickBucket, error) {
+	members, err := r.Client.ZRangeByScore(ctx, tenantClicksKey(tenantID, shortCode), &redis.ZRangeBy{
+		Min: fmt.Sprintf("%d", from.UnixNano()),
+		Max: fmt.Sprintf("%d", to.UnixNano()),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	switch groupBy {
+	case "day", "hour", "country", "referrer":
+	default:
+		return nil, fmt.Errorf("unsupported groupBy value: %s", groupBy)
+	}
+
+	counts := make(map[string]int64)
+	for _, raw := range members {
+		var m redisClickMember
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			continue
+		}
+
+		var key string
+		switch groupBy {
+		case "day":
+			key = m.Timestamp.Format("2006-01-02")
+		case "hour":
+			key = m.Timestamp.Format("2006-01-02T15:00")
+		case "country":
+			key = m.Country
+		case "referrer":
+			key = m.Referrer
+		}
+		counts[key]++
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buckets := make([]domain.ClickBucket, len(keys))
+	for i, k := range keys {
+		buckets[i] = domain.ClickBucket{Key: k, Count: counts[k]}
+	}
+	return buckets, nil
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"math/rand"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"bitly/internal/domain"
@@ -16,18 +18,141 @@ const (
 	ShortCodeLength = 6
 	ShortCodeChars  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	MaxRetries      = 5 // Define a max number of retries to prevent infinite loops
+
+	AliasMinLength = 3
+	AliasMaxLength = 32
+
+	// CodeGenRandom is the original mode: generate a random code and retry on collision.
+	CodeGenRandom = "random"
+	// CodeGenCounter derives the code from the repository's monotonic counter sequence,
+	// encoded as base62, so no collision retries are needed. The unique index on
+	// (tenantID, shortCode) remains as a safety net only.
+	CodeGenCounter = "counter"
+
+	base62Chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// counterMask is XORed into the counter sequence before encoding so that consecutive
+	// codes ("1", "2", "3", ...) don't trivially enumerate every short URL ever created.
+	// It carries no security guarantee beyond that; it's an obfuscation, not a secret.
+	counterMask int64 = 0x5bd1e995
+
+	// clickEventBuffer bounds the Redirect->worker channel; once full, new click events are
+	// dropped rather than blocking the redirect hot path.
+	clickEventBuffer = 1024
+	// clickBatchSize is the max number of events the worker batches into one repository write.
+	clickBatchSize = 50
+	// clickFlushInterval bounds how long a partial batch can sit before being flushed.
+	clickFlushInterval = 2 * time.Second
 )
 
+// aliasPattern restricts custom aliases to a URL-safe charset.
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// reservedAliases cannot be claimed as custom aliases because they collide with existing
+// or future routes (e.g. /shorten/reserved would be ambiguous with /shorten/{shortCode}).
+var reservedAliases = map[string]bool{
+	"shorten": true,
+	"s":       true,
+	"stats":   true,
+	"admin":   true,
+}
+
 // service implements the domain.ShortenerService interface.
 type service struct {
-	repo domain.Repository
+	repo        domain.Repository
+	codeGenMode string
+	geoIP       domain.GeoIPResolver
+	clickEvents chan domain.ClickEvent
 }
 
-// NewShortenerService creates a new shortener service instance.
-func NewShortenerService(repo domain.Repository) domain.ShortenerService {
+// NewShortenerService creates a new shortener service instance. codeGenMode selects how
+// short codes are generated (CodeGenRandom or CodeGenCounter); an empty value defaults to
+// CodeGenRandom. geoIP resolves a redirect's IP to a country for click analytics; a nil
+// resolver just leaves ClickEvent.Country blank.
+func NewShortenerService(repo domain.Repository, codeGenMode string, geoIP domain.GeoIPResolver) domain.ShortenerService {
 	// Initialize random seed once
-	rand.Seed(time.Now().UnixNano()) 
-	return &service{repo: repo}
+	rand.Seed(time.Now().UnixNano())
+	if codeGenMode == "" {
+		codeGenMode = CodeGenRandom
+	}
+	s := &service{
+		repo:        repo,
+		codeGenMode: codeGenMode,
+		geoIP:       geoIP,
+		clickEvents: make(chan domain.ClickEvent, clickEventBuffer),
+	}
+	go s.runClickWorker()
+	return s
+}
+
+// runClickWorker drains clickEvents and batch-inserts them into the repository, so Redirect
+// never waits on an analytics write. It flushes whenever a batch fills up or
+// clickFlushInterval elapses, whichever comes first.
+func (s *service) runClickWorker() {
+	batch := make([]domain.ClickEvent, 0, clickBatchSize)
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.repo.RecordClicks(ctx, batch); err != nil {
+			fmt.Printf("Error batch-inserting %d click events: %v\n", len(batch), err)
+		}
+		cancel()
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event, ok := <-s.clickEvents:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= clickBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// parseUserAgent extracts a best-effort browser and OS name from a User-Agent header. It's
+// a small heuristic match, not a full UA parser.
+func parseUserAgent(ua string) (browser, os string) {
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	default:
+		browser = "Unknown"
+	}
+
+	switch {
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		os = "iOS"
+	case strings.Contains(ua, "Mac OS"):
+		os = "macOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	default:
+		os = "Unknown"
+	}
+	return browser, os
 }
 
 // generateShortCode creates a random 6-character code without checking the database.
@@ -39,99 +164,222 @@ func (s *service) generateShortCode() string {
 	return string(b)
 }
 
+// encodeBase62 renders n as a base62 string using base62Chars. n must be non-negative.
+func encodeBase62(n int64) string {
+	if n == 0 {
+		return string(base62Chars[0])
+	}
+	base := int64(len(base62Chars))
+	var b []byte
+	for n > 0 {
+		b = append([]byte{base62Chars[n%base]}, b...)
+		n /= base
+	}
+	return string(b)
+}
+
+// nextCounterShortCode pulls the next value from the repository's counter sequence and encodes it
+// as a base62 short code, masking the counter first so codes aren't trivially enumerable.
+func (s *service) nextCounterShortCode(ctx context.Context) (string, error) {
+	seq, err := s.repo.NextSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeBase62(seq ^ counterMask), nil
+}
+
 // isValidURL checks if a string is a valid URL structure.
 func isValidURL(longURL string) bool {
 	u, err := url.ParseRequestURI(longURL)
 	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
 }
 
-// Create handles the creation of a new short URL.
-func (s *service) Create(ctx context.Context, longURL string) (domain.URL, error) {
-	if !isValidURL(longURL) {
+// validateAlias enforces the charset/length policy and the reserved-word list for
+// user-supplied aliases.
+func validateAlias(alias string) error {
+	if len(alias) < AliasMinLength || len(alias) > AliasMaxLength || !aliasPattern.MatchString(alias) {
+		return errors.New(domain.ErrInvalidAlias)
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return errors.New(domain.ErrReservedAlias)
+	}
+	return nil
+}
+
+// Create handles the creation of a new short URL scoped to tenantID.
+func (s *service) Create(ctx context.Context, tenantID string, req domain.CreateRequest) (domain.URL, error) {
+	if !isValidURL(req.URL) {
 		return domain.URL{}, errors.New(domain.ErrInvalidURL)
 	}
-	
-	// 1. Check if URL already exists
-	existingURL, err := s.repo.FindByOriginalURL(ctx, longURL)
+
+	// 1. A custom alias always mints a new mapping, even if this tenant already shortened
+	// req.URL under a different (e.g. auto-generated) code: the whole point of supplying an
+	// alias is to claim a vanity slug for a URL, and silently handing back the old mapping
+	// would discard req.Alias without telling the caller. A duplicate-key error here means
+	// the alias itself is taken within this tenant, so it must surface straight to the
+	// caller instead of being retried with a different code.
+	if req.Alias != "" {
+		if err := validateAlias(req.Alias); err != nil {
+			return domain.URL{}, err
+		}
+
+		newURL := domain.URL{
+			ID:          fmt.Sprintf("%d", time.Now().UnixNano()), // Simple unique ID
+			TenantID:    tenantID,
+			URL:         req.URL,
+			ShortCode:   req.Alias,
+			AccessCount: 0,
+			ExpiresAt:   req.ExpiresAt,
+		}
+
+		return s.repo.SaveWithAlias(ctx, newURL)
+	}
+
+	// 2. No alias requested: check if URL already exists for this tenant under some other
+	// code and reuse it instead of minting a duplicate.
+	existingURL, err := s.repo.FindByOriginalURL(ctx, tenantID, req.URL)
 	if err == nil {
 		return existingURL, errors.New(domain.ErrConflict) // Return 409 Conflict if already shortened
 	}
-	
-	// 2. Try to generate and save, retrying on shortCode collision
+
+	// 3. Counter mode: derive a deterministic code from the counter sequence. No retry loop is
+	// needed since the counter never repeats; the unique index on (tenantID, shortCode) is
+	// kept only as a safety net.
+	if s.codeGenMode == CodeGenCounter {
+		shortCode, err := s.nextCounterShortCode(ctx)
+		if err != nil {
+			return domain.URL{}, err
+		}
+
+		newURL := domain.URL{
+			ID:          fmt.Sprintf("%d", time.Now().UnixNano()),
+			TenantID:    tenantID,
+			URL:         req.URL,
+			ShortCode:   shortCode,
+			AccessCount: 0,
+			ExpiresAt:   req.ExpiresAt,
+		}
+
+		return s.repo.Save(ctx, newURL)
+	}
+
+	// 4. Random mode (fallback): generate and save, retrying on shortCode collision
 	for i := 0; i < MaxRetries; i++ {
 		// Generate code (now fast, no DB lookup)
 		shortCode := s.generateShortCode()
-		
+
 		newURL := domain.URL{
 			ID:          fmt.Sprintf("%d", time.Now().UnixNano()), // Simple unique ID
-			URL:         longURL,
+			TenantID:    tenantID,
+			URL:         req.URL,
 			ShortCode:   shortCode,
 			AccessCount: 0,
+			ExpiresAt:   req.ExpiresAt,
 		}
-		
+
 		savedURL, err := s.repo.Save(ctx, newURL)
-		
+
 		if err == nil {
 			return savedURL, nil // Success!
 		}
-		
+
 		// If the error is a shortCode collision (ErrConflict from repository), retry the loop
-		if errors.Is(err, errors.New(domain.ErrConflict)) {
+		if err.Error() == domain.ErrConflict {
 			// Collision detected, continue loop to generate a new code
-			continue 
+			continue
 		}
-		
+
 		// If it's any other error (DB error, timeout, etc.), return immediately
 		return domain.URL{}, err
 	}
-	
+
 	// If max retries reached, return an error
 	return domain.URL{}, errors.New("failed to generate unique short code after multiple attempts")
 }
 
-// Get retrieves a URL document by its short code.
-func (s *service) Get(ctx context.Context, shortCode string) (domain.URL, error) {
-	return s.repo.FindByShortCode(ctx, shortCode)
+// Get retrieves a tenant's URL document by its short code.
+func (s *service) Get(ctx context.Context, tenantID, shortCode string) (domain.URL, error) {
+	return s.repo.FindByShortCode(ctx, tenantID, shortCode)
 }
 
 // Update handles updating the long URL for an existing short code.
-func (s *service) Update(ctx context.Context, shortCode string, newURL string) (domain.URL, error) {
+func (s *service) Update(ctx context.Context, tenantID, shortCode string, newURL string) (domain.URL, error) {
 	if !isValidURL(newURL) {
 		return domain.URL{}, errors.New(domain.ErrInvalidURL)
 	}
-	return s.repo.Update(ctx, shortCode, newURL)
+	return s.repo.Update(ctx, tenantID, shortCode, newURL)
 }
 
 // Delete handles deleting a short URL.
-func (s *service) Delete(ctx context.Context, shortCode string) error {
-	return s.repo.Delete(ctx, shortCode)
+func (s *service) Delete(ctx context.Context, tenantID, shortCode string) error {
+	return s.repo.Delete(ctx, tenantID, shortCode)
 }
 
 // GetStats retrieves the URL document for statistics.
-func (s *service) GetStats(ctx context.Context, shortCode string) (domain.URL, error) {
-	return s.repo.FindByShortCode(ctx, shortCode)
+func (s *service) GetStats(ctx context.Context, tenantID, shortCode string) (domain.URL, error) {
+	return s.repo.FindByShortCode(ctx, tenantID, shortCode)
+}
+
+// GetClickStats returns a click time series for shortCode between from and to, bucketed by
+// groupBy. It 404s on an unknown shortCode rather than silently aggregating zero clicks, so
+// it's consistent with the no-groupBy branch of GetStats.
+func (s *service) GetClickStats(ctx context.Context, tenantID, shortCode string, from, to time.Time, groupBy string) ([]domain.ClickBucket, error) {
+	if _, err := s.repo.FindByShortCode(ctx, tenantID, shortCode); err != nil {
+		return nil, err
+	}
+	return s.repo.AggregateClicks(ctx, tenantID, shortCode, from, to, groupBy)
 }
 
-// Redirect handles the redirection and access count increment.
-func (s *service) Redirect(ctx context.Context, shortCode string) (string, error) {
-	u, err := s.repo.FindByShortCode(ctx, shortCode)
+// Redirect handles the redirection, the access count increment, and recording a ClickEvent
+// for analytics. tenantID is resolved by the handler from the route (subdomain or path
+// prefix), since /s/{code} is public and carries no API key.
+func (s *service) Redirect(ctx context.Context, tenantID, shortCode string, meta domain.RedirectMeta) (string, error) {
+	u, err := s.repo.FindByShortCode(ctx, tenantID, shortCode)
 	if err != nil {
 		return "", err // Propagate ErrNotFound
 	}
-	
+
 	// Increment access count asynchronously (optional, but better for performance)
 	// We run this in a separate routine to avoid blocking the redirect response.
 	go func() {
 		// Use a short, dedicated context for the background update
 		updateCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
-		
-		err := s.repo.IncrementAccessCount(updateCtx, shortCode)
+
+		err := s.repo.IncrementAccessCount(updateCtx, tenantID, shortCode)
 		if err != nil {
 			// In a real application, log this error
 			fmt.Printf("Error incrementing access count for %s: %v\n", shortCode, err)
 		}
 	}()
-	
+
+	s.enqueueClickEvent(tenantID, shortCode, meta)
+
 	return u.URL, nil
 }
+
+// enqueueClickEvent builds a ClickEvent from meta and hands it to the click worker,
+// dropping it if the buffer is full rather than blocking the redirect.
+func (s *service) enqueueClickEvent(tenantID, shortCode string, meta domain.RedirectMeta) {
+	event := domain.ClickEvent{
+		TenantID:       tenantID,
+		ShortCode:      shortCode,
+		Timestamp:      time.Now(),
+		Referrer:       meta.Referrer,
+		UserAgent:      meta.UserAgent,
+		AcceptLanguage: meta.AcceptLanguage,
+	}
+	event.Browser, event.OS = parseUserAgent(meta.UserAgent)
+
+	if s.geoIP != nil {
+		if country, err := s.geoIP.Country(meta.IP); err == nil {
+			event.Country = country
+		}
+	}
+
+	select {
+	case s.clickEvents <- event:
+	default:
+		fmt.Printf("Click event buffer full, dropping event for %s\n", shortCode)
+	}
+}